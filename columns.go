@@ -0,0 +1,46 @@
+package main
+
+import "strings"
+
+// tagColumnNames lists the identifier columns that name a row (iface,
+// device, ...), as opposed to the measured values beside them.
+// Classification is by column name, not by Go type, so a string-valued
+// field like "status" or "error" stays a field instead of becoming an
+// unbounded-cardinality tag.
+var tagColumnNames = map[string]bool{
+	"id":         true,
+	"iface":      true,
+	"device":     true,
+	"mountpoint": true,
+	"disk":       true,
+	"name":       true,
+	"image":      true,
+	"metric":     true,
+}
+
+func isTagColumn(name string) bool {
+	return tagColumnNames[name] || strings.HasPrefix(name, "label_")
+}
+
+// classifyRow splits one row into its tag and field columns, shared by
+// every output and the Prometheus endpoint.
+func classifyRow(columns []string, row []interface{}) (tagNames, tagValues []string, fieldNames []string, fieldValues []interface{}) {
+	for i, col := range columns {
+		if i >= len(row) {
+			continue
+		}
+
+		if isTagColumn(col) {
+			if s, ok := row[i].(string); ok {
+				tagNames = append(tagNames, col)
+				tagValues = append(tagValues, s)
+				continue
+			}
+		}
+
+		fieldNames = append(fieldNames, col)
+		fieldValues = append(fieldValues, row[i])
+	}
+
+	return tagNames, tagValues, fieldNames, fieldValues
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+var configFlag string
+
+func init() {
+	flag.StringVar(&configFlag, "config", "", "Path to a TOML config file with per-collector intervals, tags and outputs. CLI flags override values found here.")
+}
+
+// duration lets TOML string values like "60s" decode into a time.Duration.
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// Config mirrors the layout documented for -config.
+type Config struct {
+	Global    GlobalConfig    `toml:"global"`
+	Collector CollectorConfig `toml:"collector"`
+	Output    OutputConfig    `toml:"output"`
+}
+
+type GlobalConfig struct {
+	Interval duration          `toml:"interval"`
+	Hostname string            `toml:"hostname"`
+	Tags     map[string]string `toml:"tags"`
+}
+
+type CollectorConfig struct {
+	CPU     []CPUConfig     `toml:"cpu"`
+	Network []NetworkConfig `toml:"network"`
+	Disks   []DisksConfig   `toml:"disks"`
+	Exec    []ExecConfig    `toml:"exec"`
+}
+
+// ExecConfig is the TOML equivalent of one -exec flag.
+type ExecConfig struct {
+	Name     string   `toml:"name"`
+	Format   string   `toml:"format"`
+	Command  string   `toml:"command"`
+	Interval duration `toml:"interval"`
+}
+
+type CPUConfig struct {
+	Interval duration `toml:"interval"`
+}
+
+type NetworkConfig struct {
+	Interval   duration `toml:"interval"`
+	Interfaces []string `toml:"interfaces"`
+	Exclude    bool     `toml:"exclude"`
+}
+
+type DisksConfig struct {
+	Interval duration `toml:"interval"`
+	Devices  []string `toml:"devices"`
+	Exclude  bool     `toml:"exclude"`
+}
+
+type OutputConfig struct {
+	InfluxDB []InfluxDBConfig `toml:"influxdb"`
+}
+
+type InfluxDBConfig struct {
+	Host     string `toml:"host"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	Database string `toml:"database"`
+}
+
+// loadConfig decodes the TOML file at path, or returns an empty Config
+// when path is "".
+func loadConfig(path string) (*Config, error) {
+	config := new(Config)
+	if path == "" {
+		return config, nil
+	}
+
+	if _, err := toml.DecodeFile(path, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
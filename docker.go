@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	dockerClient "github.com/docker/docker/client"
+)
+
+func init() {
+	gatherFuncs["docker"] = dockerContainers
+}
+
+// dockerCPUSample is the previous cycle's CPU/system usage for a container.
+type dockerCPUSample struct {
+	containerUsage uint64
+	systemUsage    uint64
+}
+
+var lastDockerCPU = make(map[string]dockerCPUSample)
+var lastDockerCPUMu sync.Mutex
+
+// dockerCPUPercent returns (cpu_delta / system_delta) * online_cpus * 100,
+// or 0 on a container's first cycle. lastDockerCPU is read and written from
+// both runDaemon's ticker and the /metrics handler, hence the lock.
+func dockerCPUPercent(id string, stats *types.StatsJSON) float64 {
+	cur := dockerCPUSample{
+		containerUsage: stats.CPUStats.CPUUsage.TotalUsage,
+		systemUsage:    stats.CPUStats.SystemUsage,
+	}
+
+	lastDockerCPUMu.Lock()
+	prev, ok := lastDockerCPU[id]
+	lastDockerCPU[id] = cur
+	lastDockerCPUMu.Unlock()
+
+	if !ok {
+		return 0
+	}
+
+	cpuDelta := float64(cur.containerUsage) - float64(prev.containerUsage)
+	systemDelta := float64(cur.systemUsage) - float64(prev.systemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+
+	return (cpuDelta / systemDelta) * onlineCPUs * 100.0
+}
+
+// blkioTotals sums the per-device blkio entries into one read/write count.
+func blkioTotals(entries []types.BlkioStatEntry) (read, write uint64) {
+	for _, entry := range entries {
+		switch entry.Op {
+		case "Read":
+			read += entry.Value
+		case "Write":
+			write += entry.Value
+		}
+	}
+	return read, write
+}
+
+// sortedNetworkNames keeps the emitted columns stable across cycles.
+func sortedNetworkNames(networks map[string]types.NetworkStats) []string {
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dockerContainers emits one series per running container: CPU%, memory,
+// network and blkio as fields, name/image/labels as columns.
+func dockerContainers(prefix string, ch chan []*Series) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cli, err := dockerClient.NewClientWithOpts(dockerClient.FromEnv, dockerClient.WithAPIVersionNegotiation())
+	if err != nil {
+		ch <- nil
+		return err
+	}
+	defer cli.Close()
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{})
+	if err != nil {
+		ch <- nil
+		return err
+	}
+
+	var series []*Series
+
+	for _, c := range containers {
+		inspect, err := cli.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			continue
+		}
+
+		raw, err := cli.ContainerStats(ctx, c.ID, false)
+		if err != nil {
+			continue
+		}
+
+		var stats types.StatsJSON
+		err = json.NewDecoder(raw.Body).Decode(&stats)
+		raw.Body.Close()
+		if err != nil {
+			continue
+		}
+
+		serie := &Series{
+			Name:    prefix + "docker",
+			Columns: []string{"name", "image", "cpu_percent", "mem_usage", "mem_limit"},
+			Points:  [][]interface{}{},
+		}
+		point := []interface{}{
+			strings.TrimPrefix(c.Names[0], "/"),
+			c.Image,
+			dockerCPUPercent(c.ID, &stats),
+			stats.MemoryStats.Usage,
+			stats.MemoryStats.Limit,
+		}
+
+		for _, iface := range sortedNetworkNames(stats.Networks) {
+			serie.Columns = append(serie.Columns, iface+"_rx_bytes", iface+"_tx_bytes")
+			point = append(point, stats.Networks[iface].RxBytes, stats.Networks[iface].TxBytes)
+		}
+
+		readBytes, writeBytes := blkioTotals(stats.BlkioStats.IoServiceBytesRecursive)
+		serie.Columns = append(serie.Columns, "blkio_read_bytes", "blkio_write_bytes")
+		point = append(point, readBytes, writeBytes)
+
+		for label, value := range inspect.Config.Labels {
+			serie.Columns = append(serie.Columns, "label_"+label)
+			point = append(point, value)
+		}
+
+		serie.Points = append(serie.Points, point)
+		series = append(series, serie)
+	}
+
+	ch <- series
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestDockerCPUPercentFirstCycle(t *testing.T) {
+	lastDockerCPUMu.Lock()
+	delete(lastDockerCPU, "c1")
+	lastDockerCPUMu.Unlock()
+
+	stats := &types.StatsJSON{}
+	stats.CPUStats.CPUUsage.TotalUsage = 1000
+	stats.CPUStats.SystemUsage = 10000
+	stats.CPUStats.OnlineCPUs = 4
+
+	if got := dockerCPUPercent("c1", stats); got != 0 {
+		t.Errorf("first cycle = %v, want 0 (nothing to diff against yet)", got)
+	}
+}
+
+func TestDockerCPUPercentSecondCycle(t *testing.T) {
+	lastDockerCPUMu.Lock()
+	delete(lastDockerCPU, "c2")
+	lastDockerCPUMu.Unlock()
+
+	first := &types.StatsJSON{}
+	first.CPUStats.CPUUsage.TotalUsage = 1000
+	first.CPUStats.SystemUsage = 10000
+	first.CPUStats.OnlineCPUs = 2
+	dockerCPUPercent("c2", first)
+
+	second := &types.StatsJSON{}
+	second.CPUStats.CPUUsage.TotalUsage = 1500
+	second.CPUStats.SystemUsage = 11000
+	second.CPUStats.OnlineCPUs = 2
+
+	want := (500.0 / 1000.0) * 2 * 100.0
+	if got := dockerCPUPercent("c2", second); got != want {
+		t.Errorf("second cycle = %v, want %v", got, want)
+	}
+}
+
+func TestBlkioTotals(t *testing.T) {
+	entries := []types.BlkioStatEntry{
+		{Op: "Read", Value: 100},
+		{Op: "Write", Value: 50},
+		{Op: "Read", Value: 25},
+		{Op: "Total", Value: 999},
+	}
+
+	read, write := blkioTotals(entries)
+	if read != 125 || write != 50 {
+		t.Errorf("blkioTotals = (%d, %d), want (125, 50)", read, write)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// execSpecs collects every repeated -exec flag occurrence.
+type execSpecs []string
+
+func (e *execSpecs) String() string { return strings.Join(*e, ",") }
+
+func (e *execSpecs) Set(value string) error {
+	*e = append(*e, value)
+	return nil
+}
+
+var execFlag execSpecs
+var execTimeoutFlag time.Duration
+
+func init() {
+	flag.Var(&execFlag, "exec", "Run an external command on its own interval and parse its output, as 'name:format=command arg1 arg2@30s' (format is influx, json or graphite; @interval defaults to -interval). Repeatable.")
+	flag.DurationVar(&execTimeoutFlag, "exec-timeout", 5*time.Second, "Maximum time an -exec command may run before its process group is killed.")
+}
+
+var execSpecRe = regexp.MustCompile(`^([^:]+):(influx|json|graphite)=(.+?)(?:@(.+))?$`)
+
+// execCollector runs one external command and parses its stdout.
+type execCollector struct {
+	name    string
+	format  string
+	command string
+	args    []string
+}
+
+// parseExecSpec splits a "name:format=command args@interval" -exec value
+// into the collector it describes and its interval (daemonIntervalFlag
+// when @interval is omitted).
+func parseExecSpec(spec string) (*execCollector, time.Duration, error) {
+	m := execSpecRe.FindStringSubmatch(spec)
+	if m == nil {
+		return nil, 0, fmt.Errorf("invalid -exec spec `%s'", spec)
+	}
+
+	fields := strings.Fields(m[3])
+	if len(fields) == 0 {
+		return nil, 0, fmt.Errorf("invalid -exec spec `%s': empty command", spec)
+	}
+
+	interval := daemonIntervalFlag
+	if m[4] != "" {
+		parsed, err := time.ParseDuration(m[4])
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid -exec spec `%s': %s", spec, err)
+		}
+		interval = parsed
+	}
+
+	return &execCollector{name: m[1], format: m[2], command: fields[0], args: fields[1:]}, interval, nil
+}
+
+// buildExecJobs turns every -exec flag and [[collector.exec]] entry into a collectorJob.
+func buildExecJobs(config *Config) ([]collectorJob, error) {
+	var jobs []collectorJob
+
+	for _, spec := range execFlag {
+		collector, interval, err := parseExecSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, collector.job(interval))
+	}
+
+	for _, ec := range config.Collector.Exec {
+		fields := strings.Fields(ec.Command)
+		if len(fields) == 0 {
+			continue
+		}
+
+		collector := &execCollector{name: ec.Name, format: ec.Format, command: fields[0], args: fields[1:]}
+		interval := ec.Interval.Duration
+		if interval == 0 {
+			interval = daemonIntervalFlag
+		}
+
+		jobs = append(jobs, collector.job(interval))
+	}
+
+	return jobs, nil
+}
+
+func (e *execCollector) job(interval time.Duration) collectorJob {
+	return collectorJob{name: "exec." + e.name, fn: e.gather, interval: interval}
+}
+
+func (e *execCollector) gather(prefix string, ch chan []*Series) error {
+	serie, err := e.run(prefix)
+	if err != nil {
+		ch <- nil
+		return err
+	}
+
+	ch <- []*Series{serie}
+	return nil
+}
+
+// run executes the command under a timeout, killing its process group if it
+// overruns, then parses its stdout. A non-zero exit adds an "error" column
+// instead of failing outright.
+func (e *execCollector) run(prefix string) (*Series, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeoutFlag)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.command, e.args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	runErr := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		if cmd.Process != nil {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+		return nil, fmt.Errorf("exec %s: killed after %s", e.name, execTimeoutFlag)
+	}
+
+	serie, parseErr := e.parse(prefix, stdout.Bytes())
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	if runErr != nil {
+		addErrorColumn(serie, runErr.Error())
+	}
+
+	return serie, nil
+}
+
+func (e *execCollector) parse(prefix string, output []byte) (*Series, error) {
+	switch e.format {
+	case "influx":
+		return parseInfluxOutput(prefix, e.name, output)
+	case "json":
+		return parseJSONOutput(prefix, e.name, output)
+	case "graphite":
+		return parseGraphiteOutput(prefix, e.name, output)
+	default:
+		return nil, fmt.Errorf("exec %s: unknown format `%s'", e.name, e.format)
+	}
+}
+
+// addErrorColumn appends an "error" column holding msg to every row.
+func addErrorColumn(serie *Series, msg string) {
+	if len(serie.Points) == 0 {
+		serie.Points = append(serie.Points, make([]interface{}, len(serie.Columns)))
+	}
+
+	serie.Columns = append(serie.Columns, "error")
+	for i := range serie.Points {
+		serie.Points[i] = append(serie.Points[i], msg)
+	}
+}
+
+// parseInfluxOutput reads InfluxDB line protocol, one row per line; the
+// column set is taken from the first line.
+func parseInfluxOutput(prefix, name string, output []byte) (*Series, error) {
+	serie := &Series{Name: prefix + name, Points: [][]interface{}{}}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			continue
+		}
+
+		var columns []string
+		var row []interface{}
+
+		measurementAndTags := strings.Split(parts[0], ",")
+		for _, tag := range measurementAndTags[1:] {
+			kv := strings.SplitN(tag, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			columns = append(columns, kv[0])
+			row = append(row, kv[1])
+		}
+
+		for _, field := range strings.Split(parts[1], ",") {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			columns = append(columns, kv[0])
+			row = append(row, parseInfluxFieldValue(kv[1]))
+		}
+
+		if serie.Columns == nil {
+			serie.Columns = columns
+		}
+		serie.Points = append(serie.Points, row)
+	}
+
+	return serie, nil
+}
+
+func parseInfluxFieldValue(raw string) interface{} {
+	raw = strings.TrimSuffix(raw, "i")
+	if v, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseFloat(raw, 64); err == nil {
+		return v
+	}
+	return strings.Trim(raw, `"`)
+}
+
+// parseJSONOutput reads a flat JSON object into a single-row series.
+func parseJSONOutput(prefix, name string, output []byte) (*Series, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(output, &fields); err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, 0, len(fields))
+	for k := range fields {
+		columns = append(columns, k)
+	}
+	sort.Strings(columns)
+
+	row := make([]interface{}, len(columns))
+	for i, k := range columns {
+		row[i] = fields[k]
+	}
+
+	return &Series{
+		Name:    prefix + name,
+		Columns: columns,
+		Points:  [][]interface{}{row},
+	}, nil
+}
+
+// parseGraphiteOutput reads "metric value timestamp" lines, one row per line.
+func parseGraphiteOutput(prefix, name string, output []byte) (*Series, error) {
+	serie := &Series{
+		Name:    prefix + name,
+		Columns: []string{"metric", "value"},
+		Points:  [][]interface{}{},
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+
+		serie.Points = append(serie.Points, []interface{}{fields[0], value})
+	}
+
+	return serie, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func TestParseInfluxFieldValue(t *testing.T) {
+	cases := map[string]interface{}{
+		"42i":     int64(42),
+		"3.14":    3.14,
+		`"ok"`:    "ok",
+		"running": "running",
+	}
+
+	for in, want := range cases {
+		got := parseInfluxFieldValue(in)
+		if got != want {
+			t.Errorf("parseInfluxFieldValue(%q) = %#v, want %#v", in, got, want)
+		}
+	}
+}
+
+func TestParseInfluxOutput(t *testing.T) {
+	serie, err := parseInfluxOutput("host.", "mycmd", []byte("mycmd,host=a value=1i\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if serie.Name != "host.mycmd" {
+		t.Errorf("Name = %q, want %q", serie.Name, "host.mycmd")
+	}
+	if len(serie.Points) != 1 {
+		t.Fatalf("Points = %v, want 1 row", serie.Points)
+	}
+}
+
+func TestParseJSONOutput(t *testing.T) {
+	serie, err := parseJSONOutput("host.", "mycmd", []byte(`{"status":"ok","count":3}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	row := map[string]interface{}{}
+	for i, col := range serie.Columns {
+		row[col] = serie.Points[0][i]
+	}
+
+	if row["status"] != "ok" {
+		t.Errorf("status = %#v, want \"ok\" (a string field, not a tag)", row["status"])
+	}
+	if row["count"] != float64(3) {
+		t.Errorf("count = %#v, want 3", row["count"])
+	}
+}
+
+func TestParseGraphiteOutput(t *testing.T) {
+	serie, err := parseGraphiteOutput("host.", "mycmd", []byte("myapp.requests 12 1700000000\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(serie.Points) != 1 || serie.Points[0][0] != "myapp.requests" || serie.Points[0][1] != 12.0 {
+		t.Errorf("Points = %v, want [[myapp.requests 12]]", serie.Points)
+	}
+}
+
+func TestAddErrorColumn(t *testing.T) {
+	serie, _ := parseJSONOutput("host.", "mycmd", []byte(`{"status":"ok"}`))
+	addErrorColumn(serie, "exit status 1")
+
+	tagNames, _, fieldNames, fieldValues := classifyRow(serie.Columns, serie.Points[0])
+	if stringInSlice("error", tagNames) {
+		t.Errorf("error ended up classified as a tag, want a field: tags=%v", tagNames)
+	}
+
+	found := false
+	for i, name := range fieldNames {
+		if name == "error" && fieldValues[i] == "exit status 1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("error field not found among fields: %v = %v", fieldNames, fieldValues)
+	}
+}
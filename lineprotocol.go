@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// seriesToLineProtocol renders series as InfluxDB line protocol, one
+// line per row: identifier columns (iface, device, mountpoint, ...)
+// become tags, the remaining columns become fields, and every row
+// shares the collection timestamp ts. It is shared by every output that
+// just needs raw bytes to write or publish (file, stdout, kafka, amqp).
+func seriesToLineProtocol(series []*Series, ts time.Time) []byte {
+	var buf bytes.Buffer
+
+	for _, serie := range series {
+		for _, row := range serie.Points {
+			tagNames, tagValues, fieldNames, fieldValues := classifyRow(serie.Columns, row)
+
+			var tags, fields []string
+			for i, name := range tagNames {
+				tags = append(tags, fmt.Sprintf("%s=%s", name, escapeTagValue(tagValues[i])))
+			}
+			for i, name := range fieldNames {
+				fields = append(fields, fmt.Sprintf("%s=%s", name, formatFieldValue(fieldValues[i])))
+			}
+
+			if len(fields) == 0 {
+				continue
+			}
+
+			buf.WriteString(serie.Name)
+			if len(tags) > 0 {
+				buf.WriteString(",")
+				buf.WriteString(strings.Join(tags, ","))
+			}
+			buf.WriteString(" ")
+			buf.WriteString(strings.Join(fields, ","))
+			fmt.Fprintf(&buf, " %d\n", ts.UnixNano())
+		}
+	}
+
+	return buf.Bytes()
+}
+
+var tagValueReplacer = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// escapeTagValue escapes the characters line protocol gives meaning to
+// (tag separator, key/value separator, field-set separator) in a tag value.
+func escapeTagValue(v string) string {
+	return tagValueReplacer.Replace(v)
+}
+
+var fieldStringReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// formatFieldValue renders a field value per line protocol: strings are
+// quoted (and escaped), everything else is written as-is.
+func formatFieldValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return `"` + fieldStringReplacer.Replace(s) + `"`
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSeriesToLineProtocol(t *testing.T) {
+	serie := &Series{
+		Name:    "host.network",
+		Columns: []string{"iface", "recv_bytes", "status"},
+		Points:  [][]interface{}{{"eth0", 1024, "up"}},
+	}
+	ts := time.Unix(0, 1700000000000000000)
+
+	line := string(seriesToLineProtocol([]*Series{serie}, ts))
+
+	if !strings.HasPrefix(line, "host.network,iface=eth0 ") {
+		t.Fatalf("line = %q, want iface as a tag right after the measurement", line)
+	}
+	if !strings.Contains(line, "recv_bytes=1024") {
+		t.Errorf("line = %q, missing recv_bytes field", line)
+	}
+	if !strings.Contains(line, `status="up"`) {
+		t.Errorf("line = %q, status should be a quoted string field (not dropped as a tag-only column)", line)
+	}
+	if !strings.Contains(line, " 1700000000000000000\n") {
+		t.Errorf("line = %q, missing the collection timestamp", line)
+	}
+}
+
+func TestSeriesToLineProtocolFieldWithoutAnyTags(t *testing.T) {
+	serie := &Series{
+		Name:    "host.mycmd",
+		Columns: []string{"status"},
+		Points:  [][]interface{}{{"ok"}},
+	}
+
+	line := seriesToLineProtocol([]*Series{serie}, time.Unix(0, 0))
+	if !strings.Contains(string(line), `status="ok"`) {
+		t.Errorf("line = %q, a string field with no tag columns should still be emitted", line)
+	}
+}
+
+func TestEscapeTagValue(t *testing.T) {
+	if got := escapeTagValue("a,b=c d"); got != `a\,b\=c\ d` {
+		t.Errorf("escapeTagValue = %q, want escaped commas/equals/spaces", got)
+	}
+}
+
+func TestFormatFieldValueQuotesAndEscapesStrings(t *testing.T) {
+	if got := formatFieldValue(`say "hi"`); got != `"say \"hi\""` {
+		t.Errorf("formatFieldValue = %q, want escaped quotes", got)
+	}
+	if got := formatFieldValue(42); got != "42" {
+		t.Errorf("formatFieldValue = %q, want unquoted numeric", got)
+	}
+}
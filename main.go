@@ -15,12 +15,12 @@ import (
 	"flag"
 	"fmt"
 	"github.com/cloudfoundry/gosigar"
-	influxClient "github.com/influxdb/influxdb/client"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -125,130 +125,206 @@ func main() {
 			consistencyFactor = daemonConsistencyFlag.Seconds() / daemonIntervalFlag.Seconds()
 		}
 
-		// Fill InfluxDB connection settings
-		var client *influxClient.Client = nil
-		if databaseFlag != "" {
-			config := new(influxClient.ClientConfig)
-
-			config.Host = hostFlag
-			config.Username = usernameFlag
-			config.Database = databaseFlag
+		config, err := loadConfig(configFlag)
+		if err != nil {
+			panic(err)
+		}
+		applyConfig(config)
 
-			// use secret file if present, fallback to CLI password arg
-			if secretFlag != "" {
-				data, err := ioutil.ReadFile(secretFlag)
-				if err != nil {
-					panic(err)
-				}
-				config.Password = strings.Split(string(data), "\n")[0]
-			} else {
-				config.Password = passwordFlag
+		// Build the fan-out list of output backends from -output/-outputfilter
+		// plus any [[output.influxdb]] blocks from -config
+		outputs, err := buildOutputs()
+		if err != nil {
+			panic(err)
+		}
+		for _, oc := range config.Output.InfluxDB {
+			influxOutput, err := NewInfluxDBOutput(oc.Host, oc.Username, oc.Password, "", oc.Database)
+			if err != nil {
+				panic(err)
 			}
+			outputs = append(outputs, influxOutput)
+		}
 
-			var err error
-			client, err = influxClient.NewClient(config)
-
+		// Wrap every output in a write-ahead spool so a downed output
+		// doesn't lose data collected while it's unreachable
+		for i, output := range outputs {
+			spooled, err := NewSpooledOutput(fmt.Sprintf("output%d", i), output, spoolDirFlag, spoolMaxSizeFlag)
 			if err != nil {
 				panic(err)
 			}
+			outputs[i] = spooled
+			spooledOutputs = append(spooledOutputs, spooled)
+		}
+		for _, output := range outputs {
+			defer output.Close()
 		}
 
-		// Build collect list
-		var collectList []GatherFunc
-		for _, c := range strings.Split(collectFlag, ",") {
-			switch strings.Trim(c, " ") {
-			case "cpu":
-				collectList = append(collectList, cpu)
-			case "cpus":
-				collectList = append(collectList, cpus)
-			case "mem":
-				collectList = append(collectList, mem)
-			case "swap":
-				collectList = append(collectList, swap)
-			case "uptime":
-				collectList = append(collectList, uptime)
-			case "load":
-				collectList = append(collectList, load)
-			case "network":
-				collectList = append(collectList, network)
-			case "disks":
-				collectList = append(collectList, disks)
-			case "mounts":
-				collectList = append(collectList, mounts)
-			default:
-				fmt.Fprintf(os.Stderr, "Unknown collect option `%s'\n", c)
-				return
-			}
+		jobs := buildCollectorJobs(config)
+		if len(spooledOutputs) > 0 {
+			jobs = append(jobs, collectorJob{name: "self", fn: selfStats, interval: daemonIntervalFlag})
+		}
+
+		execJobs, err := buildExecJobs(config)
+		if err != nil {
+			panic(err)
 		}
+		jobs = append(jobs, execJobs...)
 
 		if prefixFlag != "" && prefixFlag[len(prefixFlag)-1] != '.' {
 			prefixFlag += "."
 		}
 
-		ch := make(chan *influxClient.Series, len(collectList))
+		maybeServeMetrics(jobs)
 
-		// Without daemon mode, do at least one lap
-		first := true
+		if daemonFlag {
+			runDaemon(jobs, outputs)
+		} else {
+			runOnce(jobs, outputs)
+		}
+	}
+}
 
-		for first || daemonFlag {
-			first = false
+// applyConfig merges -config values into the package flags it
+// overlaps with, without touching any flag the user passed explicitly
+// on the command line.
+func applyConfig(config *Config) {
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
 
-			// Collect data
-			var data []*influxClient.Series
+	if config.Global.Hostname != "" && !explicit["prefix"] && !explicit["P"] {
+		prefixFlag = config.Global.Hostname
+	}
+	if config.Global.Interval.Duration > 0 && !explicit["interval"] && !explicit["i"] {
+		daemonIntervalFlag = config.Global.Interval.Duration
+	}
+
+	globalTags = config.Global.Tags
+
+	if len(config.Collector.Network) > 0 {
+		if nc := config.Collector.Network[0]; len(nc.Interfaces) > 0 {
+			networkFilter = &nameFilter{names: nc.Interfaces, exclude: nc.Exclude}
+		}
+	}
+	if len(config.Collector.Disks) > 0 {
+		if dc := config.Collector.Disks[0]; len(dc.Devices) > 0 {
+			diskFilter = &nameFilter{names: dc.Devices, exclude: dc.Exclude}
+		}
+	}
+}
 
-			for _, cl := range collectList {
-				go cl(prefixFlag, ch)
+// runOnce collects exactly one lap of every job and hands it to the
+// output fan-out, looping internally only to give diffed collectors
+// (which need a previous sample to compare against) a second try.
+func runOnce(jobs []collectorJob, outputs []Output) {
+	ch := make(chan []*Series, len(jobs))
+	first := true
+
+	for first {
+		first = false
+
+		var data []*Series
+		cycleTime := time.Now()
+
+		for _, job := range jobs {
+			go job.fn(prefixFlag, ch)
+		}
+
+		for i := len(jobs); i > 0; i-- {
+			res := <-ch
+			if res != nil {
+				data = append(data, res...)
+			} else {
+				// Loop if we haven't all data: since diffed data
+				// didn't respond the first time they are collected,
+				// loop one more time to have it
+				first = true
 			}
+		}
 
-			for i := len(collectList); i > 0; i-- {
-				res := <-ch
-				if res != nil {
-					data = append(data, res)
-				} else if !daemonFlag {
-					// Loop if we haven't all data:
-					// Since diffed data didn't respond the
-					// first time they are collected, loop
-					// one more time to have it
-					first = true
-				}
+		if !first {
+			processCycle(data, outputs, cycleTime)
+		} else {
+			time.Sleep(daemonIntervalFlag)
+		}
+	}
+}
+
+// runDaemon starts one goroutine per collector, each driven by its own
+// time.Ticker so collectors configured with different intervals don't
+// have to wait on each other, and fans every result out as soon as it
+// arrives on the shared channel. processCycle runs in its own goroutine
+// per result, so a cycle stuck waiting on outputTimeoutFlag for a stuck
+// output can't delay this loop from picking up the next collector's result.
+func runDaemon(jobs []collectorJob, outputs []Output) {
+	ch := make(chan []*Series, len(jobs))
+
+	for _, job := range jobs {
+		go func(job collectorJob) {
+			ticker := time.NewTicker(job.interval)
+			defer ticker.Stop()
+
+			for {
+				job.fn(prefixFlag, ch)
+				<-ticker.C
 			}
+		}(job)
+	}
 
-			if !first {
-				if fqdnFlag {
-					for _, serie := range data {
-						serie.Columns = append(serie.Columns, "fqdn")
-						for kv, value := range serie.Points {
-							serie.Points[kv] = append(value, getFqdn())
-						}
-					}
-				}
-
-				// Show data
-				if !first && (databaseFlag == "" || verboseFlag != "") {
-					if strings.ToLower(verboseFlag) == "text" || verboseFlag == "" {
-						prettyPrinter(data)
-					} else {
-						b, _ := json.Marshal(data)
-						fmt.Printf("%s\n", b)
-					}
-				}
-
-				// Send data
-				if client != nil {
-					if err := send(client, data); err != nil {
-						fmt.Fprintf(os.Stderr, "%s\n", err)
-					}
-				}
+	for res := range ch {
+		if res != nil {
+			go processCycle(res, outputs, time.Now())
+		}
+	}
+}
+
+// processCycle tags a cycle's series with fqdn/global tags when
+// enabled, prints it if requested, and fans it out to every output.
+func processCycle(data []*Series, outputs []Output, cycleTime time.Time) {
+	if fqdnFlag {
+		for _, serie := range data {
+			serie.Columns = append(serie.Columns, "fqdn")
+			for kv, value := range serie.Points {
+				serie.Points[kv] = append(value, getFqdn())
 			}
+		}
+	}
 
-			if daemonFlag || first {
-				time.Sleep(daemonIntervalFlag)
+	for tag, value := range globalTags {
+		for _, serie := range data {
+			serie.Columns = append(serie.Columns, tag)
+			for kv, pt := range serie.Points {
+				serie.Points[kv] = append(pt, value)
 			}
 		}
 	}
+
+	// Show data
+	if len(outputs) == 0 || verboseFlag != "" {
+		if strings.ToLower(verboseFlag) == "text" || verboseFlag == "" {
+			prettyPrinter(data)
+		} else {
+			b, _ := json.Marshal(data)
+			fmt.Printf("%s\n", b)
+		}
+	}
+
+	// Send data to every configured output concurrently, so one output
+	// stalling on an unreachable server can't hold up the others or the
+	// collector loop feeding this function.
+	var wg sync.WaitGroup
+	for _, output := range outputs {
+		wg.Add(1)
+		go func(output Output) {
+			defer wg.Done()
+			if err := send(output, data, cycleTime); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+			}
+		}(output)
+	}
+	wg.Wait()
 }
 
-func prettyPrinter(series []*influxClient.Series) {
+func prettyPrinter(series []*Series) {
 	for ks, serie := range series {
 		nbCols := len(serie.Columns)
 
@@ -270,11 +346,20 @@ func prettyPrinter(series []*influxClient.Series) {
 }
 
 /**
- * Interactions with InfluxDB
+ * Interactions with the output backend
  */
 
-func send(client *influxClient.Client, series []*influxClient.Series) error {
-	return client.WriteSeries(series)
+func send(output Output, series []*Series, ts time.Time) error {
+	return output.Write(series, ts)
+}
+
+// readSecret reads the first line of the password file at path.
+func readSecret(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.Split(string(data), "\n")[0], nil
 }
 
 /**
@@ -283,7 +368,7 @@ func send(client *influxClient.Client, series []*influxClient.Series) error {
 
 var last_series = make(map[string][][]interface{})
 
-func DiffFromLast(serie *influxClient.Series) *influxClient.Series {
+func DiffFromLast(serie *Series) *Series {
 	notComplete := false
 
 	if _, ok := last_series[serie.Name]; !ok {
@@ -337,14 +422,127 @@ func DiffFromLast(serie *influxClient.Series) *influxClient.Series {
 	}
 }
 
+// oneOrNone adapts a collector's single *Series (or nil,
+// meaning "not ready yet") to the []*Series a GatherFunc
+// sends on its channel, so collectors that can emit several series
+// (e.g. docker, one per container) share the same channel protocol.
+func oneOrNone(serie *Series) []*Series {
+	if serie == nil {
+		return nil
+	}
+	return []*Series{serie}
+}
+
 /**
  * Gathering functions
  */
 
-type GatherFunc func(string, chan *influxClient.Series) error
+// Series is a named table of rows sharing the same columns: the shape
+// every collector emits and every output writes out.
+type Series struct {
+	Name    string
+	Columns []string
+	Points  [][]interface{}
+}
+
+type GatherFunc func(string, chan []*Series) error
+
+var gatherFuncs = map[string]GatherFunc{
+	"cpu":     cpu,
+	"cpus":    cpus,
+	"mem":     mem,
+	"swap":    swap,
+	"uptime":  uptime,
+	"load":    load,
+	"network": network,
+	"disks":   disks,
+	"mounts":  mounts,
+}
+
+// collectorJob binds a GatherFunc to the interval it should be run on,
+// as resolved from -config (falling back to -interval/-i).
+type collectorJob struct {
+	name     string
+	fn       GatherFunc
+	interval time.Duration
+}
+
+// buildCollectorJobs turns -collect into the list of jobs run by
+// runOnce/runDaemon, resolving each job's interval against config.
+func buildCollectorJobs(config *Config) []collectorJob {
+	var jobs []collectorJob
+
+	for _, c := range strings.Split(collectFlag, ",") {
+		name := strings.Trim(c, " ")
+
+		fn, ok := gatherFuncs[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown collect option `%s'\n", name)
+			os.Exit(1)
+		}
+
+		jobs = append(jobs, collectorJob{
+			name:     name,
+			fn:       fn,
+			interval: collectorInterval(config, name),
+		})
+	}
+
+	return jobs
+}
+
+// collectorInterval returns the interval set in the matching
+// [[collector.<name>]] TOML block, or -interval/-i if there isn't one.
+func collectorInterval(config *Config, name string) time.Duration {
+	switch name {
+	case "cpu":
+		for _, c := range config.Collector.CPU {
+			if c.Interval.Duration > 0 {
+				return c.Interval.Duration
+			}
+		}
+	case "network":
+		for _, c := range config.Collector.Network {
+			if c.Interval.Duration > 0 {
+				return c.Interval.Duration
+			}
+		}
+	case "disks":
+		for _, c := range config.Collector.Disks {
+			if c.Interval.Duration > 0 {
+				return c.Interval.Duration
+			}
+		}
+	}
 
-func cpu(prefix string, ch chan *influxClient.Series) error {
-	serie := &influxClient.Series{
+	return daemonIntervalFlag
+}
+
+// nameFilter implements the interfaces/devices include & exclude lists
+// from the [[collector.network]] and [[collector.disks]] config blocks.
+type nameFilter struct {
+	names   []string
+	exclude bool
+}
+
+func (f *nameFilter) allows(name string) bool {
+	if f == nil || len(f.names) == 0 {
+		return true
+	}
+	if f.exclude {
+		return !stringInSlice(name, f.names)
+	}
+	return stringInSlice(name, f.names)
+}
+
+var networkFilter *nameFilter
+var diskFilter *nameFilter
+var globalTags map[string]string
+
+// cpuRaw collects the cumulative counters cpu() diffs, without diffing
+// them, so the Prometheus endpoint can expose raw counters instead.
+func cpuRaw(prefix string) (*Series, error) {
+	serie := &Series{
 		Name:    prefix + "cpu",
 		Columns: []string{"id", "user", "nice", "sys", "idle", "wait", "total"},
 		Points:  [][]interface{}{},
@@ -352,17 +550,27 @@ func cpu(prefix string, ch chan *influxClient.Series) error {
 
 	cpu := sigar.Cpu{}
 	if err := cpu.Get(); err != nil {
+		return nil, err
+	}
+	serie.Points = append(serie.Points, []interface{}{"cpu", cpu.User, cpu.Nice, cpu.Sys, cpu.Idle, cpu.Wait, cpu.Total()})
+
+	return serie, nil
+}
+
+func cpu(prefix string, ch chan []*Series) error {
+	serie, err := cpuRaw(prefix)
+	if err != nil {
 		ch <- nil
 		return err
 	}
-	serie.Points = append(serie.Points, []interface{}{"cpu", cpu.User, cpu.Nice, cpu.Sys, cpu.Idle, cpu.Wait, cpu.Total()})
 
-	ch <- DiffFromLast(serie)
+	ch <- oneOrNone(DiffFromLast(serie))
 	return nil
 }
 
-func cpus(prefix string, ch chan *influxClient.Series) error {
-	serie := &influxClient.Series{
+// cpusRaw is cpus() without the diffing step; see cpuRaw.
+func cpusRaw(prefix string) (*Series, error) {
+	serie := &Series{
 		Name:    prefix + "cpus",
 		Columns: []string{"id", "user", "nice", "sys", "idle", "wait", "total"},
 		Points:  [][]interface{}{},
@@ -374,12 +582,17 @@ func cpus(prefix string, ch chan *influxClient.Series) error {
 		serie.Points = append(serie.Points, []interface{}{fmt.Sprint("cpu", i), cpu.User, cpu.Nice, cpu.Sys, cpu.Idle, cpu.Wait, cpu.Total()})
 	}
 
-	ch <- DiffFromLast(serie)
+	return serie, nil
+}
+
+func cpus(prefix string, ch chan []*Series) error {
+	serie, _ := cpusRaw(prefix)
+	ch <- oneOrNone(DiffFromLast(serie))
 	return nil
 }
 
-func mem(prefix string, ch chan *influxClient.Series) error {
-	serie := &influxClient.Series{
+func mem(prefix string, ch chan []*Series) error {
+	serie := &Series{
 		Name:    prefix + "mem",
 		Columns: []string{"free", "used", "actualfree", "actualused", "total"},
 		Points:  [][]interface{}{},
@@ -392,12 +605,12 @@ func mem(prefix string, ch chan *influxClient.Series) error {
 	}
 	serie.Points = append(serie.Points, []interface{}{mem.Free, mem.Used, mem.ActualFree, mem.ActualUsed, mem.Total})
 
-	ch <- serie
+	ch <- []*Series{serie}
 	return nil
 }
 
-func swap(prefix string, ch chan *influxClient.Series) error {
-	serie := &influxClient.Series{
+func swap(prefix string, ch chan []*Series) error {
+	serie := &Series{
 		Name:    prefix + "swap",
 		Columns: []string{"free", "used", "total"},
 		Points:  [][]interface{}{},
@@ -410,12 +623,12 @@ func swap(prefix string, ch chan *influxClient.Series) error {
 	}
 	serie.Points = append(serie.Points, []interface{}{swap.Free, swap.Used, swap.Total})
 
-	ch <- serie
+	ch <- []*Series{serie}
 	return nil
 }
 
-func uptime(prefix string, ch chan *influxClient.Series) error {
-	serie := &influxClient.Series{
+func uptime(prefix string, ch chan []*Series) error {
+	serie := &Series{
 		Name:    prefix + "uptime",
 		Columns: []string{"length"},
 		Points:  [][]interface{}{},
@@ -428,12 +641,12 @@ func uptime(prefix string, ch chan *influxClient.Series) error {
 	}
 	serie.Points = append(serie.Points, []interface{}{uptime.Length})
 
-	ch <- serie
+	ch <- []*Series{serie}
 	return nil
 }
 
-func load(prefix string, ch chan *influxClient.Series) error {
-	serie := &influxClient.Series{
+func load(prefix string, ch chan []*Series) error {
+	serie := &Series{
 		Name:    prefix + "load",
 		Columns: []string{"one", "five", "fifteen"},
 		Points:  [][]interface{}{},
@@ -446,18 +659,21 @@ func load(prefix string, ch chan *influxClient.Series) error {
 	}
 	serie.Points = append(serie.Points, []interface{}{load.One, load.Five, load.Fifteen})
 
-	ch <- serie
+	ch <- []*Series{serie}
 	return nil
 }
 
-func network(prefix string, ch chan *influxClient.Series) error {
+// networkRaw is network() without the diffing step; see cpuRaw. It
+// returns a nil series (no error) for the same malformed-line case
+// network() used to signal by sending a nil on the channel.
+func networkRaw(prefix string) (*Series, error) {
 	fi, err := os.Open("/proc/net/dev")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer fi.Close()
 
-	serie := &influxClient.Series{
+	serie := &Series{
 		Name: prefix + "network",
 		Columns: []string{"iface",
 			"recv_bytes", "recv_packets", "recv_errs",
@@ -482,11 +698,13 @@ func network(prefix string, ch chan *influxClient.Series) error {
 		line := scanner.Text()
 		tmp := strings.Split(line, ":")
 		if len(tmp) < 2 {
-			ch <- nil
-			return nil
+			return nil, nil
 		}
 
 		iface := strings.Trim(tmp[0], " ")
+		if !networkFilter.allows(iface) {
+			continue
+		}
 		tmp = strings.Fields(tmp[1])
 
 		var points []interface{}
@@ -503,18 +721,33 @@ func network(prefix string, ch chan *influxClient.Series) error {
 		serie.Points = append(serie.Points, points)
 	}
 
-	ch <- DiffFromLast(serie)
+	return serie, nil
+}
+
+func network(prefix string, ch chan []*Series) error {
+	serie, err := networkRaw(prefix)
+	if err != nil {
+		ch <- nil
+		return err
+	}
+	if serie == nil {
+		ch <- nil
+		return nil
+	}
+
+	ch <- oneOrNone(DiffFromLast(serie))
 	return nil
 }
 
-func disks(prefix string, ch chan *influxClient.Series) error {
+// disksRaw is disks() without the diffing step; see cpuRaw.
+func disksRaw(prefix string) (*Series, error) {
 	fi, err := os.Open("/proc/diskstats")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer fi.Close()
 
-	serie := &influxClient.Series{
+	serie := &Series{
 		Name: prefix + "disks",
 		Columns: []string{"device",
 			"read_ios", "read_merges", "read_sectors", "read_ticks",
@@ -528,8 +761,11 @@ func disks(prefix string, ch chan *influxClient.Series) error {
 	for scanner.Scan() {
 		tmp := strings.Fields(scanner.Text())
 		if len(tmp) < 14 {
-			ch <- nil
-			return nil
+			return nil, nil
+		}
+
+		if !diskFilter.allows(tmp[2]) {
+			continue
 		}
 
 		var points []interface{}
@@ -546,12 +782,26 @@ func disks(prefix string, ch chan *influxClient.Series) error {
 		serie.Points = append(serie.Points, points)
 	}
 
-	ch <- DiffFromLast(serie)
+	return serie, nil
+}
+
+func disks(prefix string, ch chan []*Series) error {
+	serie, err := disksRaw(prefix)
+	if err != nil {
+		ch <- nil
+		return err
+	}
+	if serie == nil {
+		ch <- nil
+		return nil
+	}
+
+	ch <- oneOrNone(DiffFromLast(serie))
 	return nil
 }
 
-func mounts(prefix string, ch chan *influxClient.Series) error {
-	serie := &influxClient.Series{
+func mounts(prefix string, ch chan []*Series) error {
+	serie := &Series{
 		Name:    prefix + "mounts",
 		Columns: []string{"mountpoint", "disk", "free", "total"},
 		Points:  [][]interface{}{},
@@ -586,6 +836,6 @@ func mounts(prefix string, ch chan *influxClient.Series) error {
 		}
 	}
 
-	ch <- serie
+	ch <- []*Series{serie}
 	return nil
 }
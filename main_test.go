@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestNameFilterAllows(t *testing.T) {
+	cases := []struct {
+		filter *nameFilter
+		name   string
+		want   bool
+	}{
+		{nil, "eth0", true},
+		{&nameFilter{}, "eth0", true},
+		{&nameFilter{names: []string{"eth0"}}, "eth0", true},
+		{&nameFilter{names: []string{"eth0"}}, "eth1", false},
+		{&nameFilter{names: []string{"eth0"}, exclude: true}, "eth0", false},
+		{&nameFilter{names: []string{"eth0"}, exclude: true}, "eth1", true},
+	}
+
+	for _, c := range cases {
+		if got := c.filter.allows(c.name); got != c.want {
+			t.Errorf("(%+v).allows(%q) = %v, want %v", c.filter, c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Output is implemented by every backend able to receive a cycle's series.
+// ts is the time the cycle was collected at.
+type Output interface {
+	Write(series []*Series, ts time.Time) error
+	Close() error
+}
+
+var urlFlag string
+var tokenFlag string
+var orgFlag string
+var bucketFlag string
+var outputTimeoutFlag time.Duration
+
+func init() {
+	flag.StringVar(&urlFlag, "url", "http://localhost:8086", "InfluxDB server URL (output=influxdb2 only).")
+	flag.StringVar(&tokenFlag, "token", "", "InfluxDB API token (output=influxdb2 only).")
+	flag.StringVar(&orgFlag, "org", "", "InfluxDB organization (output=influxdb2 only).")
+	flag.StringVar(&bucketFlag, "bucket", "", "InfluxDB bucket (output=influxdb2 only).")
+	flag.DurationVar(&outputTimeoutFlag, "output-timeout", 10*time.Second, "Maximum time a single Write to any output may take before it's treated as failed.")
+}
+
+// InfluxDBOutput writes series to InfluxDB's v1 HTTP /write endpoint as line
+// protocol. It talks to the server directly instead of through a client
+// library, since the only published one compatible with this module's v1
+// import path no longer builds (see go.mod).
+type InfluxDBOutput struct {
+	writeURL string
+	client   *http.Client
+}
+
+// NewInfluxDBOutput connects to an InfluxDB v1 server. If secretPath is set,
+// it is read for the password and password is ignored.
+func NewInfluxDBOutput(host, username, password, secretPath, database string) (*InfluxDBOutput, error) {
+	if secretPath != "" {
+		data, err := readSecret(secretPath)
+		if err != nil {
+			return nil, err
+		}
+		password = data
+	}
+
+	values := url.Values{}
+	values.Set("db", database)
+	if username != "" {
+		values.Set("u", username)
+		values.Set("p", password)
+	}
+
+	return &InfluxDBOutput{
+		writeURL: fmt.Sprintf("http://%s/write?%s", host, values.Encode()),
+		client:   &http.Client{},
+	}, nil
+}
+
+func (o *InfluxDBOutput) Write(series []*Series, ts time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), outputTimeoutFlag)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.writeURL, bytes.NewReader(seriesToLineProtocol(series, ts)))
+	if err != nil {
+		return err
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("influxdb write: %s: %s", resp.Status, body)
+	}
+
+	return nil
+}
+
+func (o *InfluxDBOutput) Close() error {
+	return nil
+}
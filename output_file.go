@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+)
+
+var filePathFlag string
+
+func init() {
+	flag.StringVar(&filePathFlag, "file-path", "", "Path of the file to append line-protocol points to (output=file only).")
+}
+
+// FileOutput appends line-protocol points to a local file, creating it
+// if necessary.
+type FileOutput struct {
+	file *os.File
+}
+
+func NewFileOutput(path string) (*FileOutput, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileOutput{file: file}, nil
+}
+
+func (o *FileOutput) Write(series []*Series, ts time.Time) error {
+	_, err := o.file.Write(seriesToLineProtocol(series, ts))
+	return err
+}
+
+func (o *FileOutput) Close() error {
+	return o.file.Close()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// InfluxDB2Output writes series as line-protocol points through the
+// InfluxDB v2 blocking write API. Blocking (not the default async) write
+// is deliberate: Write's return value is what tells SpooledOutput whether
+// to spool a cycle, so it must reflect whether these points actually
+// reached the server.
+type InfluxDB2Output struct {
+	client   influxdb2.Client
+	writeAPI api.WriteAPIBlocking
+}
+
+// NewInfluxDB2Output connects to an InfluxDB v2 server.
+func NewInfluxDB2Output(url, token, org, bucket string) *InfluxDB2Output {
+	client := influxdb2.NewClient(url, token)
+	writeAPI := client.WriteAPIBlocking(org, bucket)
+
+	return &InfluxDB2Output{client: client, writeAPI: writeAPI}
+}
+
+func (o *InfluxDB2Output) Write(series []*Series, ts time.Time) error {
+	var points []*write.Point
+
+	for _, serie := range series {
+		for _, row := range serie.Points {
+			tagNames, tagValues, fieldNames, fieldValues := classifyRow(serie.Columns, row)
+
+			tags := make(map[string]string, len(tagNames))
+			for i, name := range tagNames {
+				tags[name] = tagValues[i]
+			}
+
+			fields := make(map[string]interface{}, len(fieldNames))
+			for i, name := range fieldNames {
+				fields[name] = fieldValues[i]
+			}
+
+			if len(fields) == 0 {
+				continue
+			}
+
+			points = append(points, influxdb2.NewPoint(serie.Name, tags, fields, ts))
+		}
+	}
+
+	if len(points) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), outputTimeoutFlag)
+	defer cancel()
+
+	return o.writeAPI.WritePoint(ctx, points...)
+}
+
+func (o *InfluxDB2Output) Close() error {
+	o.client.Close()
+	return nil
+}
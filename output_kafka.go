@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+var kafkaBrokersFlag string
+var kafkaTopicFlag string
+
+func init() {
+	flag.StringVar(&kafkaBrokersFlag, "kafka-brokers", "", "Comma-separated list of Kafka brokers (output=kafka only).")
+	flag.StringVar(&kafkaTopicFlag, "kafka-topic", "sysinfo_influxdb", "Kafka topic to publish points to (output=kafka only).")
+}
+
+// KafkaOutput publishes line-protocol points to a Kafka topic.
+type KafkaOutput struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func NewKafkaOutput(brokers, topic string) (*KafkaOutput, error) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+
+	producer, err := sarama.NewSyncProducer(strings.Split(brokers, ","), config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KafkaOutput{producer: producer, topic: topic}, nil
+}
+
+func (o *KafkaOutput) Write(series []*Series, ts time.Time) error {
+	msg := &sarama.ProducerMessage{
+		Topic: o.topic,
+		Value: sarama.ByteEncoder(seriesToLineProtocol(series, ts)),
+	}
+
+	_, _, err := o.producer.SendMessage(msg)
+	return err
+}
+
+func (o *KafkaOutput) Close() error {
+	return o.producer.Close()
+}
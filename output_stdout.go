@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// StdoutOutput writes line-protocol points to standard output. Mostly
+// useful to inspect what other outputs would send, or to pipe into
+// another tool.
+type StdoutOutput struct{}
+
+func NewStdoutOutput() *StdoutOutput {
+	return &StdoutOutput{}
+}
+
+func (o *StdoutOutput) Write(series []*Series, ts time.Time) error {
+	_, err := os.Stdout.Write(seriesToLineProtocol(series, ts))
+	return err
+}
+
+func (o *StdoutOutput) Close() error {
+	return nil
+}
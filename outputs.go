@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var outputFlag string
+var outputFilterFlag string
+
+func init() {
+	flag.StringVar(&outputFlag, "output", "influxdb1", "Comma-separated list of outputs to enable: influxdb1, influxdb2, kafka, amqp, file, stdout.")
+	flag.StringVar(&outputFilterFlag, "outputfilter", "", "Like -collect, restrict at runtime which of the -output sinks are actually active (default: all of them).")
+}
+
+// buildOutputs constructs every output named in -output, skipping those
+// whose -outputfilter excludes them and those left unconfigured (e.g.
+// influxdb1 without a -database). The fan-out in main() writes the same
+// cycle to every Output returned here.
+func buildOutputs() ([]Output, error) {
+	names := strings.Split(outputFlag, ",")
+
+	var filter []string
+	if outputFilterFlag != "" {
+		filter = strings.Split(outputFilterFlag, ",")
+	}
+
+	var outputs []Output
+	for _, name := range names {
+		name = strings.Trim(name, " ")
+		if name == "" {
+			continue
+		}
+		if filter != nil && !stringInSlice(name, filter) {
+			continue
+		}
+
+		output, err := newNamedOutput(name)
+		if err != nil {
+			return nil, err
+		}
+		if output != nil {
+			outputs = append(outputs, output)
+		}
+	}
+
+	return outputs, nil
+}
+
+func newNamedOutput(name string) (Output, error) {
+	switch name {
+	case "influxdb1":
+		if databaseFlag == "" {
+			return nil, nil
+		}
+		return NewInfluxDBOutput(hostFlag, usernameFlag, passwordFlag, secretFlag, databaseFlag)
+	case "influxdb2":
+		if tokenFlag == "" || orgFlag == "" || bucketFlag == "" {
+			return nil, nil
+		}
+		return NewInfluxDB2Output(urlFlag, tokenFlag, orgFlag, bucketFlag), nil
+	case "kafka":
+		if kafkaBrokersFlag == "" {
+			return nil, nil
+		}
+		return NewKafkaOutput(kafkaBrokersFlag, kafkaTopicFlag)
+	case "amqp":
+		if amqpURLFlag == "" {
+			return nil, nil
+		}
+		return NewAMQPOutput(amqpURLFlag, amqpExchangeFlag)
+	case "file":
+		if filePathFlag == "" {
+			return nil, nil
+		}
+		return NewFileOutput(filePathFlag)
+	case "stdout":
+		return NewStdoutOutput(), nil
+	default:
+		return nil, fmt.Errorf("unknown output `%s'", name)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var listenFlag string
+
+func init() {
+	flag.StringVar(&listenFlag, "listen", "", "Address to serve Prometheus metrics on (e.g. :9110); empty disables the endpoint.")
+}
+
+// counterCollectors are exposed as _total counters with the raw cumulative
+// value instead of going through DiffFromLast like the push path.
+var counterCollectors = map[string]bool{
+	"cpu":     true,
+	"cpus":    true,
+	"network": true,
+	"disks":   true,
+}
+
+// rawGatherFuncs is the *Raw sibling of each counterCollectors entry.
+var rawGatherFuncs = map[string]func(string) (*Series, error){
+	"cpu":     cpuRaw,
+	"cpus":    cpusRaw,
+	"network": networkRaw,
+	"disks":   disksRaw,
+}
+
+// maybeServeMetrics starts the /metrics HTTP server when -listen is set.
+func maybeServeMetrics(jobs []collectorJob) {
+	if listenFlag == "" {
+		return
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&promCollector{jobs: jobs})
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	go func() {
+		if err := http.ListenAndServe(listenFlag, mux); err != nil {
+			fmt.Fprintf(os.Stderr, "metrics server: %s\n", err)
+		}
+	}()
+}
+
+// promCollector adapts our GatherFunc collectors to prometheus.Collector.
+type promCollector struct {
+	jobs []collectorJob
+}
+
+// Describe registers nothing since a series' columns can vary between scrapes.
+func (c *promCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *promCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, job := range c.jobs {
+		for _, serie := range collectForMetrics(job) {
+			emitSeriesMetrics(ch, serie, counterCollectors[job.name])
+		}
+	}
+}
+
+// jobCache holds collectForMetrics' last result for a job, plus a lock so
+// two overlapping scrapes can't run the same job concurrently.
+type jobCache struct {
+	mu          sync.Mutex
+	series      []*Series
+	collectedAt time.Time
+}
+
+var jobCaches = make(map[string]*jobCache)
+var jobCachesMu sync.Mutex
+
+func cacheForJob(name string) *jobCache {
+	jobCachesMu.Lock()
+	defer jobCachesMu.Unlock()
+
+	c, ok := jobCaches[name]
+	if !ok {
+		c = &jobCache{}
+		jobCaches[name] = c
+	}
+	return c
+}
+
+// collectForMetrics runs one collector (preferring its raw variant when one
+// exists), but no more often than job.interval regardless of scrape rate.
+func collectForMetrics(job collectorJob) []*Series {
+	cache := cacheForJob(job.name)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if !cache.collectedAt.IsZero() && time.Since(cache.collectedAt) < job.interval {
+		return cache.series
+	}
+
+	cache.series = collect(job)
+	cache.collectedAt = time.Now()
+
+	return cache.series
+}
+
+func collect(job collectorJob) []*Series {
+	if raw, ok := rawGatherFuncs[job.name]; ok {
+		serie, err := raw(prefixFlag)
+		if err != nil || serie == nil {
+			return nil
+		}
+		return []*Series{serie}
+	}
+
+	ch := make(chan []*Series, 1)
+	job.fn(prefixFlag, ch)
+	return <-ch
+}
+
+// emitSeriesMetrics turns every row into metrics: tag columns (per
+// classifyRow) become labels, the rest become one gauge or counter each.
+func emitSeriesMetrics(ch chan<- prometheus.Metric, serie *Series, counters bool) {
+	metricPrefix := strings.ReplaceAll(strings.Trim(serie.Name, "."), ".", "_")
+
+	for _, row := range serie.Points {
+		labelNames, labelValues, fieldNames, fieldValues := classifyRow(serie.Columns, row)
+
+		for i, col := range fieldNames {
+			value, ok := toFloat64(fieldValues[i])
+			if !ok {
+				continue
+			}
+
+			name := metricPrefix + "_" + col
+			valueType := prometheus.GaugeValue
+			if counters {
+				name += "_total"
+				valueType = prometheus.CounterValue
+			}
+
+			desc := prometheus.NewDesc(name, "", labelNames, nil)
+			ch <- prometheus.MustNewConstMetric(desc, valueType, value, labelValues...)
+		}
+	}
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
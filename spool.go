@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+var spoolDirFlag string
+var spoolMaxSizeFlag int64
+
+func init() {
+	flag.StringVar(&spoolDirFlag, "spool-dir", "/var/spool/sysinfo_influxdb", "Directory batches are spooled to when an output is down.")
+	flag.Int64Var(&spoolMaxSizeFlag, "spool-max-size", 64*1024*1024, "Maximum size in bytes of a single output's spool, oldest batches evicted first.")
+}
+
+// spooledOutputs lists every SpooledOutput, for the self collector to report on.
+var spooledOutputs []*SpooledOutput
+
+// spooledBatch is what gets gob-encoded to a spool file.
+type spooledBatch struct {
+	Series []*Series
+	Time   time.Time
+}
+
+// SpoolStats are the counters the self collector exposes per output.
+type SpoolStats struct {
+	SpoolBytes int64
+	SendErrors uint64
+	Retries    uint64
+}
+
+// SpooledOutput wraps an Output with a write-ahead disk spool: a failed
+// Write is serialized under -spool-dir instead of dropped, a background
+// goroutine replays it on an exponential backoff, and it's capped at
+// -spool-max-size with FIFO eviction.
+type SpooledOutput struct {
+	name    string
+	next    Output
+	dir     string
+	maxSize int64
+
+	mu    sync.Mutex
+	bytes int64
+
+	// drainMu serializes drain(), triggered from both Write and retryLoop.
+	drainMu sync.Mutex
+
+	sendErrors uint64
+	retries    uint64
+
+	stop chan struct{}
+}
+
+// NewSpooledOutput creates dir/name and starts the background retrier.
+func NewSpooledOutput(name string, next Output, dir string, maxSize int64) (*SpooledOutput, error) {
+	dir = filepath.Join(dir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	o := &SpooledOutput{
+		name:    name,
+		next:    next,
+		dir:     dir,
+		maxSize: maxSize,
+		stop:    make(chan struct{}),
+	}
+	o.bytes = o.diskUsage()
+
+	go o.retryLoop()
+
+	return o, nil
+}
+
+func (o *SpooledOutput) Write(series []*Series, ts time.Time) error {
+	if err := o.next.Write(series, ts); err != nil {
+		atomic.AddUint64(&o.sendErrors, 1)
+		return o.spool(series, ts)
+	}
+
+	go o.drain()
+
+	return nil
+}
+
+func (o *SpooledOutput) Close() error {
+	close(o.stop)
+	return o.next.Close()
+}
+
+func (o *SpooledOutput) spool(series []*Series, ts time.Time) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(spooledBatch{Series: series, Time: ts}); err != nil {
+		return err
+	}
+
+	path := filepath.Join(o.dir, fmt.Sprintf("%020d.gob", ts.UnixNano()))
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	o.bytes += int64(buf.Len())
+	o.mu.Unlock()
+
+	o.evict()
+
+	return nil
+}
+
+// evict removes the oldest spooled batches until usage is back under
+// -spool-max-size. Filenames are zero-padded timestamps, so lexical
+// sort is chronological order.
+func (o *SpooledOutput) evict() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for o.bytes > o.maxSize {
+		files := o.sortedFiles()
+		if len(files) == 0 {
+			return
+		}
+
+		oldest := files[0]
+		if info, err := os.Stat(oldest); err == nil {
+			o.bytes -= info.Size()
+		}
+		os.Remove(oldest)
+	}
+}
+
+func (o *SpooledOutput) sortedFiles() []string {
+	entries, err := ioutil.ReadDir(o.dir)
+	if err != nil {
+		return nil
+	}
+
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, filepath.Join(o.dir, entry.Name()))
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+func (o *SpooledOutput) diskUsage() int64 {
+	var total int64
+	for _, path := range o.sortedFiles() {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// drain replays spooled batches oldest-first, stopping at the first failure.
+func (o *SpooledOutput) drain() {
+	o.drainMu.Lock()
+	defer o.drainMu.Unlock()
+
+	for _, path := range o.sortedFiles() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		var batch spooledBatch
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&batch); err != nil {
+			os.Remove(path)
+			continue
+		}
+
+		if err := o.next.Write(batch.Series, batch.Time); err != nil {
+			return
+		}
+
+		o.mu.Lock()
+		if info, statErr := os.Stat(path); statErr == nil {
+			o.bytes -= info.Size()
+		}
+		o.mu.Unlock()
+		os.Remove(path)
+	}
+}
+
+// retryLoop keeps draining the spool on an exponential backoff (1s, factor
+// 2, capped at 5m, ±20% jitter) while there's a backlog, and polls at
+// InitialInterval while there isn't, resetting the backoff so the next
+// outage starts retrying at 1s again instead of inheriting a stale
+// multi-minute interval from one that ended long ago.
+func (o *SpooledOutput) retryLoop() {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = time.Second
+	b.Multiplier = 2
+	b.MaxInterval = 5 * time.Minute
+	b.MaxElapsedTime = 0
+	b.RandomizationFactor = 0.2
+
+	for {
+		o.mu.Lock()
+		hasBacklog := o.bytes > 0
+		o.mu.Unlock()
+
+		wait := b.InitialInterval
+		if hasBacklog {
+			wait = b.NextBackOff()
+		} else {
+			b.Reset()
+		}
+
+		select {
+		case <-o.stop:
+			return
+		case <-time.After(wait):
+		}
+
+		if hasBacklog {
+			atomic.AddUint64(&o.retries, 1)
+			o.drain()
+		}
+	}
+}
+
+func (o *SpooledOutput) stats() SpoolStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return SpoolStats{
+		SpoolBytes: o.bytes,
+		SendErrors: atomic.LoadUint64(&o.sendErrors),
+		Retries:    atomic.LoadUint64(&o.retries),
+	}
+}
+
+// selfStats emits spool_bytes/send_errors/retries for every output as the
+// sysinfo_influxdb.self series.
+func selfStats(prefix string, ch chan []*Series) error {
+	serie := &Series{
+		Name:    "sysinfo_influxdb.self",
+		Columns: []string{"output", "spool_bytes", "send_errors", "retries"},
+		Points:  [][]interface{}{},
+	}
+
+	for _, output := range spooledOutputs {
+		s := output.stats()
+		serie.Points = append(serie.Points, []interface{}{output.name, s.SpoolBytes, s.SendErrors, s.Retries})
+	}
+
+	ch <- []*Series{serie}
+	return nil
+}